@@ -38,6 +38,19 @@ import (
 func main() {
 }
 
+// The backlog items below depend on PGAdapter backend behavior (wire-protocol parsing, GUCs,
+// Spanner session/transaction management) that lives in the Java backend, which is not part of
+// this tree. They are blocked until that backend is available here; no test stub is added for
+// them so that this file does not claim coverage it cannot provide.
+//   - QPC-github/pgadapter#chunk0-1: literal-value parser for INSERT/UPDATE statements.
+//   - QPC-github/pgadapter#chunk0-2: INTERVAL (OID 1186) wire codec and integer-to-interval cast.
+//   - QPC-github/pgadapter#chunk0-3: LISTEN/NOTIFY subsystem.
+//   - QPC-github/pgadapter#chunk0-5: SQL migration engine (spanner.migrate, schema_migrations).
+//   - QPC-github/pgadapter#chunk1-1: BeginTx isolation-level upgrade/warning-notice behavior.
+//   - QPC-github/pgadapter#chunk1-2: spanner.read_only_staleness GUC / TimestampBound threading.
+//   - QPC-github/pgadapter#chunk1-3: ctx-cancel session cleanup and active-session-count surface.
+//   - QPC-github/pgadapter#chunk1-4: spanner.retry_aborts_internally GUC and ABORTED replay loop.
+
 //export TestHelloWorld
 func TestHelloWorld(connString string) *C.char {
 	ctx := context.Background()
@@ -0,0 +1,428 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "C"
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// This file mirrors a subset of the tests in pgadapter_pgx_tests/pgx.go, but is built against the
+// pgx v5 client instead of v4. It exists to surface protocol regressions that only appear with the
+// v5 client's non-blocking connection and the new bind/describe sequencing (pgtype.Map replaces the
+// v4 ConnInfo type registry, and QueryExecMode replaces the prefer_simple_protocol option).
+// Test errors are returned as C strings.
+
+// An empty main method is required to build a shard C lib.
+func main() {
+}
+
+//export TestHelloWorld
+func TestHelloWorld(connString string) *C.char {
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, connString)
+	if err != nil {
+		return C.CString(err.Error())
+	}
+	defer conn.Close(ctx)
+
+	var greeting string
+	err = conn.QueryRow(ctx, "select 'Hello world!' as hello").Scan(&greeting)
+	if err != nil {
+		return C.CString(err.Error())
+	}
+	if g, w := greeting, "Hello world!"; g != w {
+		return C.CString(fmt.Sprintf("greeting mismatch\n Got: %v\nWant: %v", g, w))
+	}
+
+	return nil
+}
+
+//export TestQueryAllDataTypes
+func TestQueryAllDataTypes(connString string, oid, format int16) *C.char {
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, connString)
+	if err != nil {
+		return C.CString(err.Error())
+	}
+	defer conn.Close(ctx)
+
+	var bigintValue int64
+	var boolValue bool
+	var byteaValue []byte
+	var float8Value float64
+	var intValue int
+	var numericValue pgtype.Numeric
+	var timestamptzValue time.Time
+	var dateValue time.Time
+	var varcharValue string
+	var jsonbValue string
+
+	var row pgx.Row
+	if oid != 0 {
+		m := conn.TypeMap()
+		formats := make(pgx.QueryResultFormatsByOID)
+		for _, o := range []uint32{
+			pgtype.Int8OID, pgtype.BoolOID, pgtype.ByteaOID, pgtype.Float8OID, pgtype.Int4OID,
+			pgtype.NumericOID, pgtype.TimestamptzOID, pgtype.DateOID, pgtype.VarcharOID,
+			pgtype.JSONBOID} {
+			formats[o] = m.FormatCodeForOID(o)
+		}
+		formats[uint32(oid)] = format
+		row = conn.QueryRow(ctx, "SELECT * FROM all_types WHERE col_bigint=1", formats)
+	} else {
+		row = conn.QueryRow(ctx, "SELECT * FROM all_types WHERE col_bigint=1")
+	}
+	err = row.Scan(
+		&bigintValue,
+		&boolValue,
+		&byteaValue,
+		&float8Value,
+		&intValue,
+		&numericValue,
+		&timestamptzValue,
+		&dateValue,
+		&varcharValue,
+		&jsonbValue,
+	)
+	if err != nil {
+		return C.CString(fmt.Sprintf("Failed to execute query: %v", err.Error()))
+	}
+	if g, w := bigintValue, int64(1); g != w {
+		return C.CString(fmt.Sprintf("value mismatch\n Got: %v\nWant: %v", g, w))
+	}
+	if g, w := boolValue, true; g != w {
+		return C.CString(fmt.Sprintf("value mismatch\n Got: %v\nWant: %v", g, w))
+	}
+	if g, w := byteaValue, []byte("test"); !reflect.DeepEqual(g, w) {
+		return C.CString(fmt.Sprintf("value mismatch\n Got: %v\nWant: %v", g, w))
+	}
+	if g, w := float8Value, 3.14; g != w {
+		return C.CString(fmt.Sprintf("value mismatch\n Got: %v\nWant: %v", g, w))
+	}
+	if g, w := intValue, 100; g != w {
+		return C.CString(fmt.Sprintf("value mismatch\n Got: %v\nWant: %v", g, w))
+	}
+	var wantNumericValue pgtype.Numeric
+	_ = wantNumericValue.Scan("6.626")
+	if g, w := numericValue, wantNumericValue; !reflect.DeepEqual(g, w) {
+		return C.CString(fmt.Sprintf("value mismatch\n Got: %v\nWant: %v", g, w))
+	}
+	wantDateValue, _ := time.Parse("2006-01-02", "2022-03-29")
+	if g, w := dateValue, wantDateValue; !reflect.DeepEqual(g, w) {
+		return C.CString(fmt.Sprintf("value mismatch\n Got: %v\nWant: %v", g, w))
+	}
+	wantTimestamptzValue, _ := time.Parse(time.RFC3339Nano, "2022-02-16T13:18:02.123456+00:00")
+	if g, w := timestamptzValue.UTC().String(), wantTimestamptzValue.UTC().String(); g != w {
+		return C.CString(fmt.Sprintf("value mismatch\n Got: %v\nWant: %v", g, w))
+	}
+	if g, w := varcharValue, "test"; g != w {
+		return C.CString(fmt.Sprintf("value mismatch\n Got: %v\nWant: %v", g, w))
+	}
+	if g, w := jsonbValue, "{\"key\": \"value\"}"; g != w {
+		return C.CString(fmt.Sprintf("value mismatch\n Got: %v\nWant: %v", g, w))
+	}
+
+	return nil
+}
+
+//export TestInsertAllDataTypes
+func TestInsertAllDataTypes(connString string) *C.char {
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, connString)
+	if err != nil {
+		return C.CString(err.Error())
+	}
+	defer conn.Close(ctx)
+
+	sql := "INSERT INTO all_types (col_bigint, col_bool, col_bytea, col_float8, col_int, col_numeric, col_timestamptz, col_date, col_varchar, col_jsonb) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)"
+	numeric := pgtype.Numeric{}
+	_ = numeric.Scan("6.626")
+	timestamptz, _ := time.Parse(time.RFC3339Nano, "2022-03-24T07:39:10.123456789+01:00")
+	date := pgtype.Date{}
+	_ = date.Scan("2022-04-02")
+	tag, err := conn.Exec(ctx, sql, 100, true, []byte("test_bytes"), 3.14, 1, numeric, timestamptz, date, "test_string", "{\"key\": \"value\"}")
+	if err != nil {
+		return C.CString(fmt.Sprintf("failed to execute insert statement: %v", err))
+	}
+	if !tag.Insert() {
+		return C.CString("statement was not recognized as an insert")
+	}
+	if g, w := tag.RowsAffected(), int64(1); g != w {
+		return C.CString(fmt.Sprintf("rows affected mismatch:\n Got: %v\nWant: %v", g, w))
+	}
+
+	return nil
+}
+
+//export TestPrepareStatement
+func TestPrepareStatement(connString string) *C.char {
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, connString)
+	if err != nil {
+		return C.CString(err.Error())
+	}
+	defer conn.Close(ctx)
+
+	sql := "UPDATE all_types SET col_int=$1, col_bool=$2, col_bytea=$3, col_float8=$4, " +
+		"col_numeric=$5, col_timestamptz=$6, col_date=$7, col_varchar=$8, col_jsonb=$9 WHERE col_bigint=$10"
+	sd, err := conn.Prepare(ctx, "update_all_types", sql)
+	if err != nil {
+		return C.CString(err.Error())
+	}
+	if g, w := len(sd.ParamOIDs), 10; g != w {
+		return C.CString(fmt.Sprintf("param type count mismatch:\n Got: %v\nWant: %v", g, w))
+	}
+	wantParamTypes := []int{
+		pgtype.Int8OID,
+		pgtype.BoolOID,
+		pgtype.ByteaOID,
+		pgtype.Float8OID,
+		pgtype.NumericOID,
+		pgtype.TimestamptzOID,
+		pgtype.DateOID,
+		pgtype.VarcharOID,
+		pgtype.VarcharOID,
+		pgtype.Int8OID,
+	}
+	for i, tp := range wantParamTypes {
+		if g, w := sd.ParamOIDs[i], uint32(tp); g != w {
+			return C.CString(fmt.Sprintf("param type mismatch for param[%v]:\n Got: %v\nWant: %v", i, g, w))
+		}
+	}
+	if g, w := len(sd.Fields), 0; g != w {
+		return C.CString(fmt.Sprintf("field count mismatch:\n Got: %v\nWant: %v", g, w))
+	}
+
+	return nil
+}
+
+//export TestInsertBatch
+func TestInsertBatch(connString string) *C.char {
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, connString)
+	if err != nil {
+		return C.CString(err.Error())
+	}
+	defer conn.Close(ctx)
+
+	batch := &pgx.Batch{}
+	batchSize := 10
+	insertBatch(batch, batchSize)
+	res := conn.SendBatch(ctx, batch)
+	for i := 0; i < batchSize; i++ {
+		tag, err := res.Exec()
+		if err != nil {
+			return C.CString(fmt.Sprintf("failed to execute insert statement %d: %v", i, err))
+		}
+		if !tag.Insert() {
+			return C.CString(fmt.Sprintf("statement %d was not recognized as an insert", i))
+		}
+		if g, w := tag.RowsAffected(), int64(1); g != w {
+			return C.CString(fmt.Sprintf("rows affected mismatch for statement %d:\n Got: %v\nWant: %v", i, g, w))
+		}
+	}
+	if err := res.Close(); err != nil {
+		return C.CString(err.Error())
+	}
+
+	return nil
+}
+
+//export TestMixedBatch
+func TestMixedBatch(connString string) *C.char {
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, connString)
+	if err != nil {
+		return C.CString(err.Error())
+	}
+	defer conn.Close(ctx)
+
+	batch := &pgx.Batch{}
+	batchSize := 5
+	insertBatch(batch, batchSize)
+	batch.Queue("select count(*) from all_types where col_bool=$1", true)
+	batch.Queue("update all_types set col_bool=false where col_bool=$1", true)
+
+	res := conn.SendBatch(ctx, batch)
+	for i := 0; i < batchSize; i++ {
+		tag, err := res.Exec()
+		if err != nil {
+			return C.CString(fmt.Sprintf("failed to execute insert statement %d: %v", i, err))
+		}
+		if !tag.Insert() {
+			return C.CString(fmt.Sprintf("statement %d was not recognized as an insert", i))
+		}
+		if g, w := tag.RowsAffected(), int64(1); g != w {
+			return C.CString(fmt.Sprintf("rows affected mismatch for statement %d:\n Got: %v\nWant: %v", i, g, w))
+		}
+	}
+	var count int64
+	if err := res.QueryRow().Scan(&count); err != nil {
+		return C.CString(fmt.Sprintf("failed to get row count: %v", err.Error()))
+	}
+	tag, err := res.Exec()
+	if err != nil {
+		return C.CString(fmt.Sprintf("failed to execute update: %v", err.Error()))
+	}
+	if !tag.Update() {
+		return C.CString("update statement was not recognized as an update")
+	}
+	if g, w := tag.RowsAffected(), count; g != w {
+		return C.CString(fmt.Sprintf("rows affected mismatch for update statement:\n Got: %v\nWant: %v", g, w))
+	}
+	if err := res.Close(); err != nil {
+		return C.CString(err.Error())
+	}
+
+	return nil
+}
+
+//export TestBatchError
+func TestBatchError(connString string) *C.char {
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, connString)
+	if err != nil {
+		return C.CString(err.Error())
+	}
+	defer conn.Close(ctx)
+
+	batch := &pgx.Batch{}
+	batchSize := 5
+	insertBatch(batch, batchSize)
+	// This statement will fail.
+	batch.Queue("select count(*) from non_existent_table where col_bool=$1", true)
+	// This statement will not be executed as the previous statement failed.
+	batch.Queue("update all_types set col_bool=false where col_bool=$1", true)
+
+	res := conn.SendBatch(ctx, batch)
+
+	// Try to get results from the batch execution. Even though the error occurred for the select
+	// statement, it is returned for the first statement in the batch.
+	_, err = res.Exec()
+	if err == nil {
+		return C.CString(fmt.Sprintf("expected error for batch, but got nil"))
+	}
+	if err := res.Close(); err != nil {
+		return C.CString(err.Error())
+	}
+
+	return nil
+}
+
+func insertBatch(batch *pgx.Batch, batchSize int) {
+	sql := "INSERT INTO all_types (col_bigint, col_bool, col_bytea, col_float8, col_int, col_numeric, col_timestamptz, col_date, col_varchar, col_jsonb) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)"
+	numeric := pgtype.Numeric{}
+	for i := 0; i < batchSize; i++ {
+		_ = numeric.Scan(strconv.Itoa(i) + ".123")
+		date := &pgtype.Date{}
+		_ = date.Scan(fmt.Sprintf("2022-04-%02d", i+1))
+		timestamptz, _ := time.Parse(time.RFC3339Nano, fmt.Sprintf("2022-03-24T%02d:39:10.123456000Z", i))
+		batch.Queue(sql, 100+i, i%2 == 0, []byte(strconv.Itoa(i)+"test_bytes"), 3.14+float64(i), i, numeric, timestamptz, date, "test_string"+strconv.Itoa(i), fmt.Sprintf("{\"key\": \"value%v\"}", i))
+	}
+}
+
+//export TestCopyIn
+func TestCopyIn(connString string) *C.char {
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, connString)
+	if err != nil {
+		return C.CString(err.Error())
+	}
+	defer conn.Close(ctx)
+
+	numeric := pgtype.Numeric{}
+	_ = numeric.Scan("6.626")
+	timestamptz, _ := time.Parse(time.RFC3339Nano, "2022-03-24T12:39:10.123456000Z")
+	date := pgtype.Date{}
+	_ = date.Scan("2022-07-01")
+	jsonb := "{\"key\": \"value\"}"
+	rows := [][]interface{}{
+		{1, true, []byte{1, 2, 3}, 3.14, 10, numeric, timestamptz, date, "test", jsonb},
+		{2, nil, nil, nil, nil, nil, nil, nil, nil, nil},
+	}
+	count, err := conn.CopyFrom(
+		ctx,
+		pgx.Identifier{"all_types"},
+		[]string{"col_bigint", "col_bool", "col_bytea", "col_float8", "col_int", "col_numeric", "col_timestamptz", "col_date", "col_varchar", "col_jsonb"},
+		pgx.CopyFromRows(rows),
+	)
+	if err != nil {
+		return C.CString(fmt.Sprintf("failed to execute COPY statement: %v", err))
+	}
+	if g, w := count, int64(2); g != w {
+		return C.CString(fmt.Sprintf("rows affected mismatch:\n Got: %v\nWant: %v", g, w))
+	}
+
+	return nil
+}
+
+//export TestReadWriteTransaction
+func TestReadWriteTransaction(connString string) *C.char {
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, connString)
+	if err != nil {
+		return C.CString(err.Error())
+	}
+	defer conn.Close(ctx)
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return C.CString(fmt.Sprintf("failed to begin transaction: %v", err.Error()))
+	}
+
+	// Execute a query in a read/write transaction.
+	var value int64
+	err = tx.QueryRow(ctx, "SELECT 1").Scan(&value)
+	if err != nil {
+		return C.CString(err.Error())
+	}
+	if g, w := value, int64(1); g != w {
+		return C.CString(fmt.Sprintf("value mismatch\n Got: %v\nWant: %v", g, w))
+	}
+
+	sql := "INSERT INTO all_types (col_bigint, col_bool, col_bytea, col_float8, col_int, col_numeric, col_timestamptz, col_date, col_varchar, col_jsonb) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)"
+	numeric := pgtype.Numeric{}
+	_ = numeric.Scan("6.626")
+	timestamptz, _ := time.Parse(time.RFC3339Nano, "2022-03-24T07:39:10.123456789+01:00")
+	var tag pgconn.CommandTag
+	date := pgtype.Date{}
+	_ = date.Scan("2022-04-02")
+	for _, id := range []int64{10, 20} {
+		tag, err = tx.Exec(ctx, sql, id, true, []byte("test_bytes"), 3.14, 1, numeric, timestamptz, date, "test_string", "{\"key\": \"value\"}")
+		if err != nil {
+			return C.CString(fmt.Sprintf("failed to execute insert statement: %v", err))
+		}
+		if !tag.Insert() {
+			return C.CString("statement was not recognized as an insert")
+		}
+		if g, w := tag.RowsAffected(), int64(1); g != w {
+			return C.CString(fmt.Sprintf("rows affected mismatch:\n Got: %v\nWant: %v", g, w))
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return C.CString(fmt.Sprintf("failed to commit transaction: %v", err))
+	}
+
+	return nil
+}